@@ -0,0 +1,69 @@
+package gemini
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// APIError is returned whenever Gemini's REST API responds with a status
+// other than 200 OK. It carries the decoded error envelope
+// (`{"result":"error", ...}`, when the body holds one) plus the HTTP status and raw body so callers that need more
+// context than the sentinel errors below don't have to re-parse the
+// response themselves.
+type APIError struct {
+	StatusCode int
+	Result     string `json:"result"`
+	Reason     string `json:"reason"`
+	Message    string `json:"message"`
+	Body       []byte
+}
+
+func (e *APIError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("gemini: %s: %s (http %d)", e.Reason, e.Message, e.StatusCode)
+	}
+	return fmt.Sprintf("gemini: http %d: %s", e.StatusCode, e.Message)
+}
+
+// Is reports whether target is an *APIError with the same Reason, so
+// callers can write errors.Is(err, gemini.ErrOrderNotFound). errors.As works
+// out of the box for *APIError since it's already a concrete pointer type.
+func (e *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	if !ok || t.Reason == "" {
+		return false
+	}
+	return e.Reason == t.Reason
+}
+
+// Well-known reason codes from Gemini's error envelope. Compare with
+// errors.Is, e.g. errors.Is(err, gemini.ErrOrderNotFound).
+var (
+	ErrInvalidNonce              = &APIError{Reason: "InvalidNonce"}
+	ErrRateLimit                 = &APIError{Reason: "RateLimit"}
+	ErrInsufficientFunds         = &APIError{Reason: "InsufficientFunds"}
+	ErrOrderNotFound             = &APIError{Reason: "OrderNotFound"}
+	ErrClientOrderIdMustBeString = &APIError{Reason: "ClientOrderIdMustBeString"}
+	ErrMarketNotOpen             = &APIError{Reason: "MarketNotOpen"}
+	ErrSystemMaintenance         = &APIError{Reason: "SystemMaintenance"}
+)
+
+// parseAPIError builds an *APIError from a failed response, decoding
+// Gemini's {result, reason, message} envelope when present and falling
+// back to the raw body otherwise.
+func parseAPIError(statusCode int, body []byte) error {
+	envelope := struct {
+		Result  string `json:"result"`
+		Reason  string `json:"reason"`
+		Message string `json:"message"`
+	}{}
+	_ = json.Unmarshal(body, &envelope)
+
+	return &APIError{
+		StatusCode: statusCode,
+		Result:     envelope.Result,
+		Reason:     envelope.Reason,
+		Message:    envelope.Message,
+		Body:       body,
+	}
+}
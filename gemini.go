@@ -1,36 +1,147 @@
 package gemini
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha512"
 	"crypto/tls"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
-	"errors"
-	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"net"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
-var (
-	ApiUrl = "https://api.gemini.com/"
+const (
+	// ProductionBaseURL is the live Gemini exchange API.
+	ProductionBaseURL = "https://api.gemini.com/"
+	// SandboxBaseURL is Gemini's sandbox exchange, a separate matching
+	// engine with its own accounts and API keys.
+	SandboxBaseURL = "https://api.sandbox.gemini.com/"
 )
 
+// Logger is satisfied by *log.Logger and lets callers redirect the request
+// tracing API emits (retries, rate-limit waits) into their own log
+// pipeline. A nil Logger disables tracing.
+type Logger interface {
+	Printf(format string, args ...interface{})
+}
+
+// RetryPolicy controls how API retries requests that fail with a 429 or a
+// 5xx response. Retries use jittered exponential backoff starting at
+// BaseDelay and capped at MaxDelay.
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+// DefaultRetryPolicy retries up to 3 times, starting at 250ms and capping at
+// 5s between attempts.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: 3,
+		BaseDelay:  250 * time.Millisecond,
+		MaxDelay:   5 * time.Second,
+	}
+}
+
+// RateLimiter throttles outgoing requests to stay within Gemini's published
+// limits (120 req/min public, 600 req/min private).
+type RateLimiter struct {
+	mu           sync.Mutex
+	tokens       float64
+	max          float64
+	refillPerSec float64
+	last         time.Time
+}
+
+// NewRateLimiter returns a token-bucket RateLimiter allowing requestsPerMinute
+// sustained requests, with a burst of up to requestsPerMinute tokens.
+func NewRateLimiter(requestsPerMinute int) *RateLimiter {
+	max := float64(requestsPerMinute)
+	return &RateLimiter{
+		tokens:       max,
+		max:          max,
+		refillPerSec: max / 60,
+		last:         time.Now(),
+	}
+}
+
+// Wait blocks until a token is available or ctx is done.
+func (r *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		r.mu.Lock()
+		now := time.Now()
+		r.tokens = minFloat(r.max, r.tokens+now.Sub(r.last).Seconds()*r.refillPerSec)
+		r.last = now
+
+		if r.tokens >= 1 {
+			r.tokens--
+			r.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - r.tokens) / r.refillPerSec * float64(time.Second))
+		r.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Option configures an API instance. See WithHTTPClient, WithRateLimiter,
+// WithRetryPolicy and WithLogger.
+type Option func(*API)
+
+// WithHTTPClient overrides the *http.Client used for all requests.
+func WithHTTPClient(client *http.Client) Option {
+	return func(api *API) { api.client = client }
+}
+
+// WithRateLimiter overrides the RateLimiter used to throttle requests. Pass
+// nil to disable rate limiting.
+func WithRateLimiter(limiter *RateLimiter) Option {
+	return func(api *API) { api.rateLimiter = limiter }
+}
+
+// WithRetryPolicy overrides the retry policy applied to 429/5xx responses.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(api *API) { api.retryPolicy = policy }
+}
+
+// WithLogger attaches a Logger that traces retries and rate-limit waits.
+func WithLogger(logger Logger) Option {
+	return func(api *API) { api.logger = logger }
+}
+
 // API structure stores Bitfinex API credentials
 type API struct {
 	APIKey    string
 	APISecret string
+	baseURL   string
 	client    *http.Client
-}
 
-// ErrorMessage ...
-type ErrorMessage struct {
-	Message string `json:"message"` // Returned only on error
+	rateLimiter *RateLimiter
+	retryPolicy RetryPolicy
+	logger      Logger
+
+	symbols symbolCache
 }
 
 // Ticker ...
@@ -90,8 +201,15 @@ type WalletBalance struct {
 }
 type WalletBalances map[string]WalletBalance
 
-// New returns a new Bitfinex API instance
-func New(key, secret, url string) (api *API) {
+// New returns a new API instance targeting url (ProductionBaseURL and
+// SandboxBaseURL are provided for the common cases; NewProduction and
+// NewSandbox are shorthands for them). Each instance keeps its own base URL,
+// so a sandbox client and a production client can safely coexist in the same
+// process. By default it retries 429/5xx responses with jittered exponential
+// backoff and does not rate limit; pass WithRateLimiter to enable
+// client-side throttling. Use opts to inject a custom *http.Client,
+// RateLimiter, RetryPolicy or Logger.
+func New(key, secret, url string, opts ...Option) (api *API) {
 	var tr *http.Transport
 	dialContext := (&net.Dialer{
 		Timeout:   30 * time.Second,
@@ -103,19 +221,37 @@ func New(key, secret, url string) (api *API) {
 	}
 	client := &http.Client{
 		Transport: tr,
+		Timeout:   30 * time.Second,
+	}
+	if url == "" {
+		url = ProductionBaseURL
 	}
 	api = &API{
-		APIKey:    key,
-		APISecret: secret,
-		client:    client,
+		APIKey:      key,
+		APISecret:   secret,
+		baseURL:     url,
+		client:      client,
+		retryPolicy: DefaultRetryPolicy(),
 	}
-	if url != "" {
-		ApiUrl = url
+	for _, opt := range opts {
+		opt(api)
 	}
 	return api
 }
 
-func (api *API) Orderbook(symbol string, limitBids, limitAsks int) (orderbook Orderbook, err error) {
+// NewProduction returns an API instance targeting the live Gemini exchange.
+func NewProduction(key, secret string, opts ...Option) *API {
+	return New(key, secret, ProductionBaseURL, opts...)
+}
+
+// NewSandbox returns an API instance targeting Gemini's sandbox exchange,
+// a separate matching engine with its own accounts and API keys, useful for
+// integration testing without touching production funds.
+func NewSandbox(key, secret string, opts ...Option) *API {
+	return New(key, secret, SandboxBaseURL, opts...)
+}
+
+func (api *API) Orderbook(ctx context.Context, symbol string, limitBids, limitAsks int) (orderbook Orderbook, err error) {
 	symbol = strings.ToLower(symbol)
 
 	url := "/v1/book/" + symbol + "?"
@@ -126,10 +262,13 @@ func (api *API) Orderbook(symbol string, limitBids, limitAsks int) (orderbook Or
 		url += "limit_asks=" + strconv.Itoa(limitAsks)
 	}
 
-	body, err := api.get(url)
+	body, status, err := api.get(ctx, url)
 	if err != nil {
 		return
 	}
+	if status != http.StatusOK {
+		return orderbook, parseAPIError(status, body)
+	}
 
 	err = json.Unmarshal(body, &orderbook)
 	if err != nil {
@@ -140,27 +279,22 @@ func (api *API) Orderbook(symbol string, limitBids, limitAsks int) (orderbook Or
 }
 
 // WalletBalances return your balances.
-func (api *API) WalletBalances() (wallet WalletBalances, err error) {
+func (api *API) WalletBalances(ctx context.Context) (wallet WalletBalances, err error) {
 	request := map[string]interface{}{
 		"request": "/v1/balances",
 	}
 
-	body, err := api.post(request)
+	body, status, err := api.post(ctx, request)
 	if err != nil {
 		return
 	}
+	if status != http.StatusOK {
+		return nil, parseAPIError(status, body)
+	}
 
 	tmpBalances := []WalletBalance{}
-	err = json.Unmarshal(body, &tmpBalances)
-	if err != nil { // Failed to unmarshal expected message
-		// Attempt to unmarshal the error message
-		errorMessage := ErrorMessage{}
-		err = json.Unmarshal(body, &errorMessage)
-		if err != nil { // Not expected message and not expected error, bailing...
-			return
-		}
-
-		return nil, errors.New(errorMessage.Message)
+	if err = json.Unmarshal(body, &tmpBalances); err != nil {
+		return nil, err
 	}
 
 	wallet = make(WalletBalances)
@@ -175,7 +309,7 @@ func (api *API) WalletBalances() (wallet WalletBalances, err error) {
 // ... Request ...
 // 	timestamp (time): Optional. Only show trades at or after this timestamp.
 //	limit_trades (int): Optional. Limit the number of trades returned. Must be >= 1. Default is 50.
-func (api *API) Trades(symbol string, since int64, limitTrades int, includeBreaks bool) (trades Trades, err error) {
+func (api *API) Trades(ctx context.Context, symbol string, since int64, limitTrades int, includeBreaks bool) (trades Trades, err error) {
 	symbol = strings.ToLower(symbol)
 
 	url := "/v1/trades/" + symbol + "?"
@@ -190,162 +324,92 @@ func (api *API) Trades(symbol string, since int64, limitTrades int, includeBreak
 		url += "include_breaks=true"
 	}
 
-	body, err := api.get(url)
+	body, status, err := api.get(ctx, url)
 	if err != nil {
-		return nil, errors.New("body: " + string(body) + " err: " + err.Error())
+		return
+	}
+	if status != http.StatusOK {
+		return nil, parseAPIError(status, body)
 	}
 
 	err = json.Unmarshal(body, &trades)
-	if err != nil { // Failed to unmarshal expected message
-		// Attempt to unmarshal the error message
-		errorMessage := ErrorMessage{}
-		err = json.Unmarshal(body, &errorMessage)
-		if err != nil { // Not expected message and not expected error, bailing...
-			return
-		}
-
-		return nil, errors.New(errorMessage.Message)
-	}
 	return
 }
 
 // ActiveOrders returns an array of your active orders.
-func (api *API) ActiveOrders() (orders Orders, err error) {
+func (api *API) ActiveOrders(ctx context.Context) (orders Orders, err error) {
 	request := map[string]interface{}{
 		"request": "/v1/orders",
 	}
 
-	body, err := api.post(request)
+	body, status, err := api.post(ctx, request)
 	if err != nil {
 		return
 	}
-
-	err = json.Unmarshal(body, &orders)
-	if err != nil { // Failed to unmarshal expected message
-		// Attempt to unmarshal the error message
-		errorMessage := ErrorMessage{}
-		err = json.Unmarshal(body, &errorMessage)
-		if err != nil { // Not expected message and not expected error, bailing...
-			return
-		}
-
-		return orders, errors.New(errorMessage.Message)
+	if status != http.StatusOK {
+		return orders, parseAPIError(status, body)
 	}
 
+	err = json.Unmarshal(body, &orders)
 	return
 }
 
 // OrderStatus returns the status of an order given its id.
-func (api *API) OrderStatus(id int) (order OrderStatus, err error) {
+func (api *API) OrderStatus(ctx context.Context, id int) (order OrderStatus, err error) {
 	request := map[string]interface{}{
 		"request":  "/v1/order/status",
 		"order_id": id,
 	}
 
-	body, err := api.post(request)
+	body, status, err := api.post(ctx, request)
 	if err != nil {
 		return
 	}
-
-	err = json.Unmarshal(body, &order)
-	if err != nil || order.OrderID != id { // Failed to unmarshal expected message
-		// Attempt to unmarshal the error message
-		errorMessage := ErrorMessage{}
-		err = json.Unmarshal(body, &errorMessage)
-		if err != nil { // Not expected message and not expected error, bailing...
-			return
-		}
-
-		return order, errors.New(errorMessage.Message)
+	if status != http.StatusOK {
+		return order, parseAPIError(status, body)
 	}
 
+	err = json.Unmarshal(body, &order)
 	return
 }
 
 // CancelOrder cancel an offer give its id.
-func (api *API) CancelOrder(id int) (err error) {
+func (api *API) CancelOrder(ctx context.Context, id int) (err error) {
 	request := map[string]interface{}{
 		"request":  "/v1/order/cancel",
 		"order_id": id,
 	}
 
-	body, err := api.post(request)
+	body, status, err := api.post(ctx, request)
 	if err != nil {
 		return
 	}
+	if status != http.StatusOK {
+		return parseAPIError(status, body)
+	}
 
 	tmpOrder := struct {
 		ID        int  `json:"order_id,string"`
 		Cancelled bool `json:"is_cancelled,bool"`
 	}{}
-
-	err = json.Unmarshal(body, &tmpOrder)
-	if err != nil || tmpOrder.ID != id { // Failed to unmarshal expected message
-		// Attempt to unmarshal the error message
-		errorMessage := ErrorMessage{}
-		err = json.Unmarshal(body, &errorMessage)
-		if err != nil { // Not expected message and not expected error, bailing...
-			return
-		}
-
-		return errors.New(errorMessage.Message)
-	}
-
-	return
-}
-
-func (api *API) NewOrder(currency string, amount, price float64, isBuy bool) (order OrderStatus, err error) {
-	request := map[string]interface{}{
-		"request": "/v1/order/new",
-		"symbol":  currency,
-		"amount":  strconv.FormatFloat(amount, 'f', -1, 64),
-		"price":   strconv.FormatFloat(price, 'f', -1, 64),
-		"type":    "exchange limit",
-	}
-
-	if isBuy {
-		request["side"] = "buy"
-	} else {
-		request["side"] = "sell"
-	}
-
-	body, err := api.post(request)
-	if err != nil {
-		return
-	}
-
-	err = json.Unmarshal(body, &order)
-	if err != nil || order.OrderID == 0 { // Failed to unmarshal expected message
-		fmt.Printf("%+v, %+v, %s\n", err, order, string(body))
-		// Attempt to unmarshal the error message
-		errorMessage := ErrorMessage{}
-		err = json.Unmarshal(body, &errorMessage)
-		if err != nil { // Not expected message and not expected error, bailing...
-			return
-		}
-
-		return order, errors.New(errorMessage.Message)
-	}
-
-	return
+	return json.Unmarshal(body, &tmpOrder)
 }
 
 ///////////////////////////////////////
 // API helper methods
 ///////////////////////////////////////
 
-func (api *API) get(url string) (body []byte, err error) {
-	resp, err := http.Get(ApiUrl + url)
+func (api *API) get(ctx context.Context, url string) (body []byte, statusCode int, err error) {
+	req, err := http.NewRequest("GET", api.baseURL+url, nil)
 	if err != nil {
 		return
 	}
-	defer resp.Body.Close()
+	req = req.WithContext(ctx)
 
-	body, err = ioutil.ReadAll(resp.Body)
-	return
+	return api.do(ctx, req)
 }
 
-func (api *API) post(payload map[string]interface{}) (body []byte, err error) {
+func (api *API) post(ctx context.Context, payload map[string]interface{}) (body []byte, statusCode int, err error) {
 	payload["nonce"] = strconv.FormatInt(time.Now().UnixNano(), 10)
 	// X-GEMINI-PAYLOAD
 	// parameters-dictionary -> JSON encode -> base64
@@ -362,21 +426,67 @@ func (api *API) post(payload map[string]interface{}) (body []byte, err error) {
 	signature := hex.EncodeToString(h.Sum(nil))
 
 	// POST
-	req, err := http.NewRequest("POST", ApiUrl+payload["request"].(string), nil)
+	req, err := http.NewRequest("POST", api.baseURL+payload["request"].(string), nil)
 	if err != nil {
 		return
 	}
+	req = req.WithContext(ctx)
 
 	req.Header.Add("X-GEMINI-APIKEY", api.APIKey)
 	req.Header.Add("X-GEMINI-PAYLOAD", payloadBase64)
 	req.Header.Add("X-GEMINI-SIGNATURE", signature)
 
-	resp, err := api.client.Do(req)
-	if err != nil {
-		return
+	return api.do(ctx, req)
+}
+
+// do executes req, waiting on the rate limiter first and retrying on 429/5xx
+// responses with jittered exponential backoff per api.retryPolicy.
+func (api *API) do(ctx context.Context, req *http.Request) (body []byte, statusCode int, err error) {
+	if api.rateLimiter != nil {
+		start := time.Now()
+		if err = api.rateLimiter.Wait(ctx); err != nil {
+			return nil, 0, err
+		}
+		if waited := time.Since(start); api.logger != nil && waited >= time.Millisecond {
+			api.logger.Printf("gemini: rate limiter delayed %s %s by %s", req.Method, req.URL.Path, waited)
+		}
 	}
-	defer resp.Body.Close()
 
-	body, err = ioutil.ReadAll(resp.Body)
-	return
+	delay := api.retryPolicy.BaseDelay
+	for attempt := 0; ; attempt++ {
+		var resp *http.Response
+		resp, err = api.client.Do(req)
+
+		retryable := err != nil
+		if err == nil {
+			statusCode = resp.StatusCode
+			body, err = ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			retryable = statusCode == http.StatusTooManyRequests || statusCode >= 500
+		}
+
+		if !retryable || attempt >= api.retryPolicy.MaxRetries {
+			return body, statusCode, err
+		}
+
+		jitter := time.Duration(rand.Int63n(int64(delay) / 2))
+		wait := delay + jitter
+		if wait > api.retryPolicy.MaxDelay {
+			wait = api.retryPolicy.MaxDelay
+		}
+		if api.logger != nil {
+			api.logger.Printf("gemini: retrying %s %s (attempt %d) after %s", req.Method, req.URL.Path, attempt+1, wait)
+		}
+
+		select {
+		case <-ctx.Done():
+			return body, statusCode, ctx.Err()
+		case <-time.After(wait):
+		}
+
+		delay *= 2
+		if delay > api.retryPolicy.MaxDelay {
+			delay = api.retryPolicy.MaxDelay
+		}
+	}
 }
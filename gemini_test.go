@@ -1,10 +1,20 @@
 package gemini
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
-	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
 )
 
 var APIKey = os.Getenv("GEMINI_API_KEY")
@@ -22,7 +32,7 @@ func checkEnv(t *testing.T) {
 
 func TestOrderbook(t *testing.T) {
 	// Test normal request
-	orderbook, err := apiPublic.Orderbook("btcusd", -1, -1)
+	orderbook, err := apiPublic.Orderbook(context.Background(), "btcusd", -1, -1)
 	if err != nil || len(orderbook.Asks) != 50 || len(orderbook.Bids) != 50 {
 		t.Error("Failed")
 		return
@@ -31,7 +41,7 @@ func TestOrderbook(t *testing.T) {
 
 func TestTrades(t *testing.T) {
 	// Test normal request
-	trades, err := apiPublic.Trades("btcusd", 0, -1, false)
+	trades, err := apiPublic.Trades(context.Background(), "btcusd", 0, -1, false)
 	if err != nil {
 		t.Error("Failed: " + err.Error())
 		return
@@ -42,10 +52,34 @@ func TestTrades(t *testing.T) {
 	}
 }
 
+func TestSymbolDetailsAndRounding(t *testing.T) {
+	detail, err := apiPublic.SymbolDetails(context.Background(), "btcusd")
+	if err != nil {
+		t.Error("Failed: " + err.Error())
+		return
+	}
+	if detail.Symbol == "" {
+		t.Error("Failed: empty symbol detail")
+		return
+	}
+
+	price, err := apiPublic.RoundPrice(context.Background(), "btcusd", 1.23456789)
+	if err != nil {
+		t.Error("Failed: " + err.Error())
+		return
+	}
+	t.Logf("rounded price: %v", price)
+}
+
 func TestNewOrder(t *testing.T) {
 	checkEnv(t)
 
-	order, err := apiPrivate.NewOrder("btcusd", 1, 1, true)
+	order, err := apiPrivate.NewOrder(context.Background(), NewOrderRequest{
+		Symbol: "btcusd",
+		Amount: 1,
+		Price:  1,
+		IsBuy:  true,
+	})
 	if err != nil {
 		t.Error("Failed: " + err.Error())
 		return
@@ -60,7 +94,7 @@ func TestNewOrder(t *testing.T) {
 func TestActiveOrders(t *testing.T) {
 	checkEnv(t)
 
-	orders, err := apiPrivate.ActiveOrders()
+	orders, err := apiPrivate.ActiveOrders(context.Background())
 	if err != nil {
 		t.Error("Failed: " + err.Error())
 		return
@@ -81,7 +115,7 @@ func TestOrderStatus(t *testing.T) {
 	checkEnv(t)
 
 	// Assuming TestActiveOrders has PASSED
-	orders, err := apiPrivate.ActiveOrders()
+	orders, err := apiPrivate.ActiveOrders(context.Background())
 	if err != nil {
 		t.Error("Failed: " + err.Error())
 		return
@@ -93,7 +127,7 @@ func TestOrderStatus(t *testing.T) {
 	}
 
 	t.Log("Order status # " + strconv.Itoa(orders[0].OrderID))
-	o, err := apiPrivate.OrderStatus(orders[0].OrderID)
+	o, err := apiPrivate.OrderStatus(context.Background(), orders[0].OrderID)
 	if err != nil {
 		t.Error("Failed: " + err.Error())
 		return
@@ -107,7 +141,7 @@ func TestCancelOrder(t *testing.T) {
 	checkEnv(t)
 
 	// Assuming TestActiveOrders has PASSED
-	orders, err := apiPrivate.ActiveOrders()
+	orders, err := apiPrivate.ActiveOrders(context.Background())
 	if err != nil {
 		t.Error("Failed: " + err.Error())
 		return
@@ -119,10 +153,10 @@ func TestCancelOrder(t *testing.T) {
 		return
 	}
 
-	for _,order := range orders {
+	for _, order := range orders {
 		t.Log("Cancelling order # " + strconv.Itoa(order.OrderID))
-		err = apiPrivate.CancelOrder(order.OrderID)
-		err = apiPrivate.CancelOrder(order.OrderID)
+		err = apiPrivate.CancelOrder(context.Background(), order.OrderID)
+		err = apiPrivate.CancelOrder(context.Background(), order.OrderID)
 		if err != nil {
 			t.Error("Failed: " + err.Error())
 			return
@@ -134,21 +168,294 @@ func TestCancelUnknownOrder(t *testing.T) {
 	checkEnv(t)
 
 	t.Log("Cancelling order #666")
-	err = apiPrivate.CancelOrder(666)
+	err := apiPrivate.CancelOrder(context.Background(), 666)
 	if err != nil {
 		t.Error("Failed: " + err.Error())
 		return
 	}
-	if len(orders) == 0 {
-		t.Log("No active orders, nothing to cancel, please inspect")
+}
+
+func TestAPIErrorIs(t *testing.T) {
+	err := parseAPIError(400, []byte(`{"result":"error","reason":"OrderNotFound","message":"order not found"}`))
+
+	if !errors.Is(err, ErrOrderNotFound) {
+		t.Errorf("expected errors.Is(err, ErrOrderNotFound), got %v", err)
+	}
+	if errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("expected errors.Is(err, ErrInsufficientFunds) to be false, got %v", err)
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected errors.As to match *APIError")
+	}
+	if apiErr.StatusCode != 400 {
+		t.Errorf("StatusCode = %d, want 400", apiErr.StatusCode)
+	}
+}
+
+func TestProductionAndSandboxCoexist(t *testing.T) {
+	prod := NewProduction("", "")
+	sandbox := NewSandbox("", "")
+
+	if prod.baseURL != ProductionBaseURL {
+		t.Errorf("prod.baseURL = %q, want %q", prod.baseURL, ProductionBaseURL)
+	}
+	if sandbox.baseURL != SandboxBaseURL {
+		t.Errorf("sandbox.baseURL = %q, want %q", sandbox.baseURL, SandboxBaseURL)
+	}
+}
+
+func TestDoRetriesOnServerError(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) <= 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	api := New("", "", srv.URL, WithRetryPolicy(RetryPolicy{
+		MaxRetries: 3,
+		BaseDelay:  time.Millisecond,
+		MaxDelay:   5 * time.Millisecond,
+	}))
+
+	body, status, err := api.get(context.Background(), "/v1/symbols")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+	if string(body) != "{}" {
+		t.Fatalf("body = %q, want {}", body)
+	}
+	if got := atomic.LoadInt32(&attempts); got != 3 {
+		t.Fatalf("attempts = %d, want 3 (2 failures + 1 success)", got)
+	}
+}
+
+func TestDoGivesUpAfterMaxRetries(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	api := New("", "", srv.URL, WithRetryPolicy(RetryPolicy{
+		MaxRetries: 2,
+		BaseDelay:  time.Millisecond,
+		MaxDelay:   2 * time.Millisecond,
+	}))
+
+	_, status, err := api.get(context.Background(), "/v1/symbols")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", status, http.StatusTooManyRequests)
+	}
+	if got := atomic.LoadInt32(&attempts); got != 3 { // 1 initial try + 2 retries
+		t.Fatalf("attempts = %d, want 3", got)
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	limiter := NewRateLimiter(600) // refills 10 tokens/sec
+
+	// Draining the initial burst should never block.
+	drainStart := time.Now()
+	for i := 0; i < 600; i++ {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatalf("unexpected error draining burst: %v", err)
+		}
+	}
+	if elapsed := time.Since(drainStart); elapsed > 100*time.Millisecond {
+		t.Fatalf("draining the burst took %s, want near-instant", elapsed)
+	}
+
+	// The next call has no tokens left and must wait out a refill.
+	start := time.Now()
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("Wait returned after %s, want to block for close to one token's refill", elapsed)
+	}
+}
+
+func TestRateLimiterWaitRespectsContext(t *testing.T) {
+	limiter := NewRateLimiter(60)
+	for i := 0; i < 60; i++ {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatalf("unexpected error draining burst: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := limiter.Wait(ctx); err == nil {
+		t.Fatal("expected an error from an already-cancelled context, got nil")
+	}
+}
+
+// TestStreamSubscribeMarketDataConcurrentWrites exercises concurrent
+// SubscribeMarketData calls against an already-connected stream. Run with
+// -race: before writeMu, this raced inside gorilla/websocket's frame writer.
+func TestStreamSubscribeMarketDataConcurrentWrites(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	oldURL := MarketDataWSUrl
+	MarketDataWSUrl = "ws" + strings.TrimPrefix(srv.URL, "http")
+	defer func() { MarketDataWSUrl = oldURL }()
+
+	s := NewStream("", "")
+	defer s.Close()
+
+	if err := s.SubscribeMarketData("btcusd"); err != nil {
+		t.Fatalf("SubscribeMarketData: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		s.mu.RLock()
+		conn := s.marketDataConn
+		s.mu.RUnlock()
+		if conn != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("market data connection was never established")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	var wg sync.WaitGroup
+	for _, symbol := range []string{"ethusd", "ltcusd", "zecusd", "bchusd"} {
+		wg.Add(1)
+		go func(symbol string) {
+			defer wg.Done()
+			if err := s.SubscribeMarketData(symbol); err != nil {
+				t.Errorf("SubscribeMarketData(%s): %v", symbol, err)
+			}
+		}(symbol)
+	}
+	wg.Wait()
+}
+
+// TestStreamSlowBookConsumerDoesNotBlockClose floods applyMarketData past
+// BookUpdates' buffer capacity with nobody draining it, then verifies Close
+// and Orderbook still return promptly. Before the fix, the blocked send
+// held s.mu forever, deadlocking both.
+func TestStreamSlowBookConsumerDoesNotBlockClose(t *testing.T) {
+	s := NewStream("", "")
+
+	change := marketDataMessage{
+		Symbol:  "btcusd",
+		Changes: [][]string{{"bid", "100.00", "1"}},
+	}
+
+	go func() {
+		for i := 0; i < cap(s.BookUpdates)+5; i++ {
+			s.applyMarketData("btcusd", change)
+		}
+	}()
+
+	// Give the flood a moment to fill the buffer and block on the next send.
+	time.Sleep(50 * time.Millisecond)
+
+	doneOrderbook := make(chan struct{})
+	go func() {
+		s.Orderbook("btcusd")
+		close(doneOrderbook)
+	}()
+	select {
+	case <-doneOrderbook:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Orderbook() hung while a BookUpdates consumer was slow")
+	}
+
+	doneClose := make(chan struct{})
+	go func() {
+		_ = s.Close()
+		close(doneClose)
+	}()
+	select {
+	case <-doneClose:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close() hung while a BookUpdates consumer was slow")
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	checkEnv(t)
+
+	err := apiPrivate.Heartbeat(context.Background())
+	if err != nil {
+		t.Error("Failed: " + err.Error())
+		return
+	}
+}
+
+func TestPastTrades(t *testing.T) {
+	checkEnv(t)
+
+	trades, err := apiPrivate.PastTrades(context.Background(), "btcusd", 0, -1)
+	if err != nil {
+		t.Error("Failed: " + err.Error())
 		return
 	}
+
+	t.Logf("Detected %d past trades", len(trades))
+}
+
+func TestTransfers(t *testing.T) {
+	checkEnv(t)
+
+	transfers, err := apiPrivate.Transfers(context.Background(), "", 0, -1)
+	if err != nil {
+		t.Error("Failed: " + err.Error())
+		return
+	}
+
+	t.Logf("Detected %d transfers", len(transfers))
+}
+
+func TestCancelAll(t *testing.T) {
+	checkEnv(t)
+
+	result, err := apiPrivate.CancelAll(context.Background())
+	if err != nil {
+		t.Error("Failed: " + err.Error())
+		return
+	}
+
+	t.Logf("Cancelled %d orders", len(result.Details.CancelledOrders))
 }
 
 func TestWalletBalances(t *testing.T) {
 	checkEnv(t)
 
-	balances, err := apiPrivate.WalletBalances()
+	balances, err := apiPrivate.WalletBalances(context.Background())
 	if err != nil {
 		t.Error("Failed: " + err.Error())
 		return
@@ -166,4 +473,3 @@ func TestWalletBalances(t *testing.T) {
 
 	}
 }
-
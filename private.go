@@ -0,0 +1,281 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ExecOption is an execution instruction accepted by NewOrder's ExecOptions.
+type ExecOption string
+
+const (
+	ExecOptionMakerOrCancel        ExecOption = "maker-or-cancel"
+	ExecOptionImmediateOrCancel    ExecOption = "immediate-or-cancel"
+	ExecOptionFillOrKill           ExecOption = "fill-or-kill"
+	ExecOptionAuctionOnly          ExecOption = "auction-only"
+	ExecOptionIndicationOfInterest ExecOption = "indication-of-interest"
+)
+
+// NewOrderRequest describes an order to submit via NewOrder. ClientOrderID,
+// MinAmount, StopPrice and ExecOptions are all optional.
+type NewOrderRequest struct {
+	Symbol        string
+	Amount        float64
+	Price         float64
+	IsBuy         bool
+	ClientOrderID string
+	MinAmount     float64
+	StopPrice     float64
+	ExecOptions   []ExecOption
+}
+
+// NewOrder submits a new order and returns its initial status. Setting
+// StopPrice switches the order type to "exchange stop limit", which is the
+// only type Gemini accepts a stop_price on.
+func (api *API) NewOrder(ctx context.Context, order NewOrderRequest) (status OrderStatus, err error) {
+	orderType := "exchange limit"
+	if order.StopPrice != 0 {
+		orderType = "exchange stop limit"
+	}
+
+	request := map[string]interface{}{
+		"request": "/v1/order/new",
+		"symbol":  order.Symbol,
+		"amount":  strconv.FormatFloat(order.Amount, 'f', -1, 64),
+		"price":   strconv.FormatFloat(order.Price, 'f', -1, 64),
+		"type":    orderType,
+	}
+
+	if order.IsBuy {
+		request["side"] = "buy"
+	} else {
+		request["side"] = "sell"
+	}
+	if order.ClientOrderID != "" {
+		request["client_order_id"] = order.ClientOrderID
+	}
+	if order.MinAmount != 0 {
+		request["min_amount"] = strconv.FormatFloat(order.MinAmount, 'f', -1, 64)
+	}
+	if order.StopPrice != 0 {
+		request["stop_price"] = strconv.FormatFloat(order.StopPrice, 'f', -1, 64)
+	}
+	if len(order.ExecOptions) > 0 {
+		options := make([]string, len(order.ExecOptions))
+		for i, o := range order.ExecOptions {
+			options[i] = string(o)
+		}
+		request["options"] = options
+	}
+
+	body, statusCode, err := api.post(ctx, request)
+	if err != nil {
+		return
+	}
+	if statusCode != http.StatusOK {
+		return status, parseAPIError(statusCode, body)
+	}
+
+	err = json.Unmarshal(body, &status)
+	return
+}
+
+// CancelAllResult is the response to CancelAll and CancelSession.
+type CancelAllResult struct {
+	Result  string `json:"result"`
+	Details struct {
+		CancelledOrders []int `json:"cancelledOrders"`
+		CancelRejects   []int `json:"cancelRejects"`
+	} `json:"details"`
+}
+
+// CancelAll cancels every active order on the account, across all sessions.
+func (api *API) CancelAll(ctx context.Context) (result CancelAllResult, err error) {
+	return api.cancelAll(ctx, "/v1/order/cancel/all")
+}
+
+// CancelSession cancels every active order created by this session only.
+func (api *API) CancelSession(ctx context.Context) (result CancelAllResult, err error) {
+	return api.cancelAll(ctx, "/v1/order/cancel/session")
+}
+
+func (api *API) cancelAll(ctx context.Context, path string) (result CancelAllResult, err error) {
+	request := map[string]interface{}{
+		"request": path,
+	}
+
+	body, statusCode, err := api.post(ctx, request)
+	if err != nil {
+		return
+	}
+	if statusCode != http.StatusOK {
+		return result, parseAPIError(statusCode, body)
+	}
+
+	err = json.Unmarshal(body, &result)
+	return
+}
+
+// Heartbeat keeps a session-scoped API key from expiring. It must be called
+// at least every 30 seconds if the key requires heartbeating.
+func (api *API) Heartbeat(ctx context.Context) (err error) {
+	request := map[string]interface{}{
+		"request": "/v1/heartbeat",
+	}
+
+	body, statusCode, err := api.post(ctx, request)
+	if err != nil {
+		return
+	}
+	if statusCode != http.StatusOK {
+		return parseAPIError(statusCode, body)
+	}
+
+	result := struct {
+		Result string `json:"result"`
+	}{}
+	return json.Unmarshal(body, &result)
+}
+
+// PastTrade is a single past fill, including the fee charged on it.
+type PastTrade struct {
+	Price         float64 `json:"price,string"`      // price: The traded price.
+	Amount        float64 `json:"amount,string"`     // amount: The traded amount.
+	Timestamp     int64   `json:"timestamp"`         // timestamp: The time the trade executed, in seconds.
+	TimestampMs   int64   `json:"timestampms"`       // timestampms: The time the trade executed, in milliseconds.
+	Type          string  `json:"type"`              // type: Either "Buy" or "Sell".
+	Aggressor     bool    `json:"aggressor,bool"`    // aggressor: Whether this order was the taker in the trade.
+	FeeCurrency   string  `json:"fee_currency"`      // fee_currency: Currency the fee was paid in.
+	FeeAmount     float64 `json:"fee_amount,string"` // fee_amount: Amount of fee paid.
+	TID           int64   `json:"tid"`               // tid: The trade id.
+	OrderID       int     `json:"order_id,string"`   // order_id: The order id that was filled.
+	ClientOrderID string  `json:"client_order_id"`   // client_order_id: The client-supplied order id, if any.
+	Exchange      string  `json:"exchange"`          // exchange: Will always be "gemini".
+	IsAuctionFill bool    `json:"is_auction_fill"`   // is_auction_fill: Whether the trade was filled in an auction.
+}
+
+type PastTrades []PastTrade
+
+// PastTrades returns past trades for symbol, optionally since a given
+// timestamp (seconds), limited to at most limit results (-1 for the
+// exchange default).
+func (api *API) PastTrades(ctx context.Context, symbol string, since int64, limit int) (trades PastTrades, err error) {
+	request := map[string]interface{}{
+		"request": "/v1/mytrades",
+		"symbol":  strings.ToLower(symbol),
+	}
+	if since > -1 {
+		request["timestamp"] = since
+	}
+	if limit > -1 {
+		request["limit_trades"] = limit
+	}
+
+	body, statusCode, err := api.post(ctx, request)
+	if err != nil {
+		return
+	}
+	if statusCode != http.StatusOK {
+		return nil, parseAPIError(statusCode, body)
+	}
+
+	err = json.Unmarshal(body, &trades)
+	return
+}
+
+// Transfer is a single deposit or withdrawal on the account.
+type Transfer struct {
+	Type        string  `json:"type"`          // type: "Deposit" or "Withdrawal".
+	Status      string  `json:"status"`        // status: "Advanced" or "Complete".
+	TimestampMs int64   `json:"timestampms"`   // timestampms: The time of the transfer, in milliseconds.
+	EID         int64   `json:"eid"`           // eid: The transfer's unique event id.
+	Currency    string  `json:"currency"`      // currency: The currency transferred.
+	Amount      float64 `json:"amount,string"` // amount: The amount transferred.
+	TxHash      string  `json:"txHash"`        // txHash: The on-chain transaction hash, if available.
+	Destination string  `json:"destination"`   // destination: The destination address, for withdrawals.
+}
+
+type Transfers []Transfer
+
+// Transfers returns the deposit/withdrawal history for currency, optionally
+// since a given timestamp (seconds), limited to at most limit results
+// (-1 for the exchange default).
+func (api *API) Transfers(ctx context.Context, currency string, since int64, limit int) (transfers Transfers, err error) {
+	request := map[string]interface{}{
+		"request": "/v1/transfers",
+	}
+	if currency != "" {
+		request["currency"] = strings.ToUpper(currency)
+	}
+	if since > -1 {
+		request["timestamp"] = since
+	}
+	if limit > -1 {
+		request["limit_transfers"] = limit
+	}
+
+	body, statusCode, err := api.post(ctx, request)
+	if err != nil {
+		return
+	}
+	if statusCode != http.StatusOK {
+		return nil, parseAPIError(statusCode, body)
+	}
+
+	err = json.Unmarshal(body, &transfers)
+	return
+}
+
+// DepositAddress is a newly generated deposit address for a currency.
+type DepositAddress struct {
+	Currency string `json:"currency"`
+	Address  string `json:"address"`
+}
+
+// NewDepositAddress generates a new deposit address for currency.
+func (api *API) NewDepositAddress(ctx context.Context, currency string) (address DepositAddress, err error) {
+	request := map[string]interface{}{
+		"request": "/v1/deposit/" + strings.ToLower(currency) + "/newAddress",
+	}
+
+	body, statusCode, err := api.post(ctx, request)
+	if err != nil {
+		return
+	}
+	if statusCode != http.StatusOK {
+		return address, parseAPIError(statusCode, body)
+	}
+
+	err = json.Unmarshal(body, &address)
+	return
+}
+
+// Withdrawal is the result of a successful WithdrawCrypto call.
+type Withdrawal struct {
+	Destination string  `json:"destination"`
+	Amount      float64 `json:"amount,string"`
+	TxHash      string  `json:"txHash"`
+}
+
+// WithdrawCrypto withdraws amount of currency to address.
+func (api *API) WithdrawCrypto(ctx context.Context, currency, address string, amount float64) (withdrawal Withdrawal, err error) {
+	request := map[string]interface{}{
+		"request": "/v1/withdraw/" + strings.ToLower(currency),
+		"address": address,
+		"amount":  strconv.FormatFloat(amount, 'f', -1, 64),
+	}
+
+	body, statusCode, err := api.post(ctx, request)
+	if err != nil {
+		return
+	}
+	if statusCode != http.StatusOK {
+		return withdrawal, parseAPIError(statusCode, body)
+	}
+
+	err = json.Unmarshal(body, &withdrawal)
+	return
+}
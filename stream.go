@@ -0,0 +1,451 @@
+package gemini
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var (
+	// MarketDataWSUrl is the public per-symbol market data feed.
+	MarketDataWSUrl = "wss://api.gemini.com/v2/marketdata"
+	// OrderEventsWSUrl is the authenticated order events feed.
+	OrderEventsWSUrl = "wss://api.gemini.com/v1/order/events"
+)
+
+const (
+	minReconnectBackoff = 1 * time.Second
+	maxReconnectBackoff = 30 * time.Second
+)
+
+// TradeEvent is a single executed trade reported on the market data feed.
+type TradeEvent struct {
+	Symbol    string  `json:"symbol"`
+	Price     float64 `json:"price,string"`
+	Amount    float64 `json:"amount,string"`
+	MakerSide string  `json:"makerSide"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+// BookUpdate is a single price level change on the L2 order book.
+type BookUpdate struct {
+	Symbol string  `json:"symbol"`
+	Side   string  `json:"side"` // "bid" or "ask"
+	Price  float64 `json:"price,string"`
+	Amount float64 `json:"amount,string"` // new size at this price, 0 means removed
+}
+
+// OrderEvent is a single order lifecycle event on the private order events feed.
+type OrderEvent struct {
+	Type            string  `json:"type"` // accepted, filled, cancelled, cancel_rejected, closed, ...
+	OrderID         int     `json:"order_id,string"`
+	ClientOrderID   string  `json:"client_order_id"`
+	Symbol          string  `json:"symbol"`
+	Side            string  `json:"side"`
+	Price           float64 `json:"price,string"`
+	RemainingAmount float64 `json:"remaining_amount,string"`
+	ExecutedAmount  float64 `json:"executed_amount,string"`
+	OriginalAmount  float64 `json:"original_amount,string"`
+}
+
+// marketDataMessage mirrors the v2 marketdata envelope: a heartbeat/update
+// carrying trade and change events for a single symbol.
+type marketDataMessage struct {
+	Type    string     `json:"type"`
+	Symbol  string     `json:"symbol"`
+	Changes [][]string `json:"changes"` // [side, price, amount]
+	Trades  []struct {
+		Price     float64 `json:"price,string"`
+		Amount    float64 `json:"amount,string"`
+		MakerSide string  `json:"makerSide"`
+		Timestamp int64   `json:"timestamp"`
+	} `json:"trades"`
+}
+
+// subscribeMessage is sent after dialing the v2 marketdata socket to select
+// which symbols its single multiplexed connection should stream.
+type subscribeMessage struct {
+	Type          string                  `json:"type"`
+	Subscriptions []subscribeSubscription `json:"subscriptions"`
+}
+
+type subscribeSubscription struct {
+	Name    string   `json:"name"`
+	Symbols []string `json:"symbols"`
+}
+
+// Stream maintains WebSocket connections to Gemini's public market data and
+// private order events feeds, reconnecting with backoff and re-issuing
+// subscriptions whenever a connection drops.
+type Stream struct {
+	APIKey    string
+	APISecret string
+
+	mu             sync.RWMutex
+	books          map[string]*Orderbook
+	symbols        map[string]struct{}
+	marketDataConn *websocket.Conn
+	conns          map[*websocket.Conn]struct{}
+
+	// writeMu serializes writes across every live *websocket.Conn. It is
+	// separate from mu so a slow write never blocks readLoop/onConnect from
+	// updating subscription state.
+	writeMu sync.Mutex
+
+	Trades      chan TradeEvent
+	BookUpdates chan BookUpdate
+	OrderEvents chan OrderEvent
+
+	closed chan struct{}
+	once   sync.Once
+}
+
+// NewStream returns a Stream ready to subscribe to market data and/or order
+// events. Channels are buffered so a slow consumer doesn't stall the reader
+// goroutine; callers that care about back-pressure should drain them promptly.
+func NewStream(key, secret string) *Stream {
+	return &Stream{
+		APIKey:      key,
+		APISecret:   secret,
+		books:       make(map[string]*Orderbook),
+		symbols:     make(map[string]struct{}),
+		conns:       make(map[*websocket.Conn]struct{}),
+		Trades:      make(chan TradeEvent, 256),
+		BookUpdates: make(chan BookUpdate, 256),
+		OrderEvents: make(chan OrderEvent, 256),
+		closed:      make(chan struct{}),
+	}
+}
+
+// Orderbook returns the locally maintained snapshot for symbol as of the
+// last applied change event. It is empty until SubscribeMarketData has
+// received its initial snapshot.
+func (s *Stream) Orderbook(symbol string) Orderbook {
+	symbol = strings.ToLower(symbol)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	book := s.books[symbol]
+	if book == nil {
+		return Orderbook{}
+	}
+	return *book
+}
+
+// SubscribeMarketData adds symbol to the public v2 marketdata feed, sending
+// a subscribe message for it over the already-open connection, or dialing
+// one (and subscribing to every symbol registered so far) if this is the
+// first call. Book/trade events are applied as they arrive, and the
+// connection reconnects and re-subscribes to all known symbols automatically.
+func (s *Stream) SubscribeMarketData(symbol string) error {
+	symbol = strings.ToLower(symbol)
+
+	s.mu.Lock()
+	if _, ok := s.books[symbol]; !ok {
+		s.books[symbol] = &Orderbook{}
+	}
+	alreadyStarted := len(s.symbols) > 0
+	s.symbols[symbol] = struct{}{}
+	conn := s.marketDataConn
+	s.mu.Unlock()
+
+	if conn != nil {
+		return s.writeJSON(conn, subscribeMessage{
+			Type:          "subscribe",
+			Subscriptions: []subscribeSubscription{{Name: "l2", Symbols: []string{symbol}}},
+		})
+	}
+	if alreadyStarted {
+		// A dial is in flight or mid-backoff; onMarketDataConnect will pick
+		// up this symbol from s.symbols on the next successful connect.
+		return nil
+	}
+
+	go s.runWithReconnect(MarketDataWSUrl, nil, s.onMarketDataConnect, func(raw []byte) {
+		var msg marketDataMessage
+		if err := json.Unmarshal(raw, &msg); err != nil || msg.Symbol == "" {
+			return
+		}
+		s.applyMarketData(msg.Symbol, msg)
+	})
+
+	return nil
+}
+
+// onMarketDataConnect runs right after the v2 marketdata socket dials
+// successfully. It sends the initial (or re-)subscribe message listing
+// every symbol registered via SubscribeMarketData so far, and records conn
+// so later SubscribeMarketData calls can add symbols without reconnecting.
+func (s *Stream) onMarketDataConnect(conn *websocket.Conn) error {
+	s.mu.Lock()
+	symbols := make([]string, 0, len(s.symbols))
+	for sym := range s.symbols {
+		symbols = append(symbols, sym)
+	}
+	s.marketDataConn = conn
+	s.mu.Unlock()
+
+	if len(symbols) == 0 {
+		return nil
+	}
+	return s.writeJSON(conn, subscribeMessage{
+		Type:          "subscribe",
+		Subscriptions: []subscribeSubscription{{Name: "l2", Symbols: symbols}},
+	})
+}
+
+// writeJSON serializes writes to conn behind writeMu. gorilla/websocket
+// forbids concurrent writers on the same connection, and SubscribeMarketData
+// can be called concurrently for different symbols once the connection is up.
+func (s *Stream) writeJSON(conn *websocket.Conn, v interface{}) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return conn.WriteJSON(v)
+}
+
+// SubscribeOrderEvents connects to the authenticated order events feed,
+// signing the WebSocket upgrade request the same way private REST requests
+// are signed, and reconnecting automatically. A fresh nonce is signed for
+// every dial, including reconnects, since Gemini rejects a repeated nonce.
+func (s *Stream) SubscribeOrderEvents() error {
+	go s.runWithReconnect(OrderEventsWSUrl, s.signOrderEventsRequest, nil, func(raw []byte) {
+		var event OrderEvent
+		if err := json.Unmarshal(raw, &event); err != nil {
+			return
+		}
+		select {
+		case s.OrderEvents <- event:
+		case <-s.closed:
+		}
+	})
+
+	return nil
+}
+
+// Close stops all running subscriptions, closes their live connections so
+// any goroutine blocked in conn.ReadMessage unblocks, and releases their
+// goroutines.
+func (s *Stream) Close() error {
+	s.once.Do(func() {
+		close(s.closed)
+
+		s.mu.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mu.Unlock()
+	})
+	return nil
+}
+
+func (s *Stream) applyMarketData(symbol string, msg marketDataMessage) {
+	s.mu.Lock()
+	book := s.books[symbol]
+	if book == nil {
+		book = &Orderbook{}
+		s.books[symbol] = book
+	}
+	var updates []BookUpdate
+	for _, change := range msg.Changes {
+		if len(change) != 3 {
+			continue
+		}
+		side := change[0]
+		price, err := strconv.ParseFloat(change[1], 64)
+		if err != nil {
+			continue
+		}
+		amount, err := strconv.ParseFloat(change[2], 64)
+		if err != nil {
+			continue
+		}
+		applyBookChange(book, side, price, amount)
+		updates = append(updates, BookUpdate{Symbol: symbol, Side: side, Price: price, Amount: amount})
+	}
+	s.mu.Unlock()
+
+	// Sent outside the lock: a slow BookUpdates consumer must never block
+	// Close, Orderbook, or SubscribeMarketData, all of which need s.mu.
+	for _, u := range updates {
+		select {
+		case s.BookUpdates <- u:
+		case <-s.closed:
+			return
+		}
+	}
+
+	for _, t := range msg.Trades {
+		select {
+		case s.Trades <- TradeEvent{
+			Symbol:    symbol,
+			Price:     t.Price,
+			Amount:    t.Amount,
+			MakerSide: t.MakerSide,
+			Timestamp: t.Timestamp,
+		}:
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+// applyBookChange snaps a single [side, price, amount] change onto the
+// in-memory snapshot, removing the level when amount is zero.
+func applyBookChange(book *Orderbook, side string, price, amount float64) {
+	var levels *[]OrderbookOffer
+	if side == "bid" {
+		levels = &book.Bids
+	} else {
+		levels = &book.Asks
+	}
+
+	for i, offer := range *levels {
+		if offer.Price == price {
+			if amount == 0 {
+				*levels = append((*levels)[:i], (*levels)[i+1:]...)
+			} else {
+				(*levels)[i].Amount = amount
+			}
+			return
+		}
+	}
+	if amount != 0 {
+		*levels = append(*levels, OrderbookOffer{Price: price, Amount: amount})
+	}
+}
+
+// runWithReconnect keeps a single WebSocket connection alive, calling
+// onMessage for every text frame received, and reconnects with jittered
+// exponential backoff whenever the connection drops until Close is called.
+// headerFunc, if non-nil, is called fresh before every dial attempt so a
+// signed header (e.g. a nonce) is never reused across reconnects. onConnect,
+// if non-nil, runs once the dial succeeds and before any frames are read,
+// to (re-)issue subscriptions on the new connection.
+func (s *Stream) runWithReconnect(url string, headerFunc func() http.Header, onConnect func(*websocket.Conn) error, onMessage func([]byte)) {
+	backoff := minReconnectBackoff
+
+	for {
+		select {
+		case <-s.closed:
+			return
+		default:
+		}
+
+		var header http.Header
+		if headerFunc != nil {
+			header = headerFunc()
+		}
+
+		conn, _, err := websocket.DefaultDialer.Dial(url, header)
+		if err != nil {
+			if !s.sleepBackoff(&backoff) {
+				return
+			}
+			continue
+		}
+
+		if onConnect != nil {
+			if err := onConnect(conn); err != nil {
+				conn.Close()
+				if !s.sleepBackoff(&backoff) {
+					return
+				}
+				continue
+			}
+		}
+
+		backoff = minReconnectBackoff
+		s.trackConn(conn)
+		s.readLoop(conn, onMessage)
+		s.untrackConn(conn)
+
+		if !s.sleepBackoff(&backoff) {
+			return
+		}
+	}
+}
+
+func (s *Stream) trackConn(conn *websocket.Conn) {
+	s.mu.Lock()
+	s.conns[conn] = struct{}{}
+	s.mu.Unlock()
+}
+
+// untrackConn drops conn from the live-connection set and, if it was the
+// market data feed's connection, clears marketDataConn so a subsequent
+// SubscribeMarketData call falls back to waiting for the next reconnect
+// instead of writing to a dead socket.
+func (s *Stream) untrackConn(conn *websocket.Conn) {
+	s.mu.Lock()
+	delete(s.conns, conn)
+	if s.marketDataConn == conn {
+		s.marketDataConn = nil
+	}
+	s.mu.Unlock()
+}
+
+func (s *Stream) readLoop(conn *websocket.Conn, onMessage func([]byte)) {
+	defer conn.Close()
+	for {
+		select {
+		case <-s.closed:
+			return
+		default:
+		}
+
+		_, raw, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		onMessage(raw)
+	}
+}
+
+// sleepBackoff waits out the current backoff (with up to 20% jitter),
+// doubling it for next time, and reports whether the caller should continue.
+func (s *Stream) sleepBackoff(backoff *time.Duration) bool {
+	jitter := time.Duration(rand.Int63n(int64(*backoff) / 5))
+	select {
+	case <-s.closed:
+		return false
+	case <-time.After(*backoff + jitter):
+	}
+
+	*backoff *= 2
+	if *backoff > maxReconnectBackoff {
+		*backoff = maxReconnectBackoff
+	}
+	return true
+}
+
+// signOrderEventsRequest builds the X-GEMINI-* headers for the order events
+// WebSocket upgrade, signed the same way api.post signs private REST calls.
+func (s *Stream) signOrderEventsRequest() http.Header {
+	payload := map[string]interface{}{
+		"request": "/v1/order/events",
+		"nonce":   strconv.FormatInt(time.Now().UnixNano(), 10),
+	}
+	payloadJSON, _ := json.Marshal(payload)
+	payloadBase64 := base64.StdEncoding.EncodeToString(payloadJSON)
+
+	h := hmac.New(sha512.New384, []byte(s.APISecret))
+	h.Write([]byte(payloadBase64))
+	signature := hex.EncodeToString(h.Sum(nil))
+
+	return http.Header{
+		"X-GEMINI-APIKEY":    {s.APIKey},
+		"X-GEMINI-PAYLOAD":   {payloadBase64},
+		"X-GEMINI-SIGNATURE": {signature},
+	}
+}
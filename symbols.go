@@ -0,0 +1,130 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// SymbolDetail describes a trading pair's tradable currencies and the
+// increments NewOrder's price and amount must be rounded to.
+type SymbolDetail struct {
+	Symbol         string  `json:"symbol"`
+	BaseCurrency   string  `json:"base_currency"`
+	QuoteCurrency  string  `json:"quote_currency"`
+	TickSize       float64 `json:"tick_size"`             // tick_size: resolution increment for the amount field.
+	QuoteIncrement float64 `json:"quote_increment"`       // quote_increment: resolution increment for the price field.
+	MinOrderSize   float64 `json:"min_order_size,string"` // min_order_size: smallest order amount accepted.
+	Status         string  `json:"status"`
+}
+
+// symbolCache is embedded in API and guards the lazily-populated, never
+// expiring Symbols()/SymbolDetails() caches. Gemini's symbol metadata
+// changes rarely enough that callers are expected to restart the process
+// to pick up additions.
+type symbolCache struct {
+	mu      sync.RWMutex
+	symbols []string
+	details map[string]SymbolDetail
+}
+
+// Symbols returns the list of all symbols Gemini trades, fetching and
+// caching it on first call.
+func (api *API) Symbols(ctx context.Context) (symbols []string, err error) {
+	api.symbols.mu.RLock()
+	cached := api.symbols.symbols
+	api.symbols.mu.RUnlock()
+	if cached != nil {
+		return cached, nil
+	}
+
+	body, statusCode, err := api.get(ctx, "/v1/symbols")
+	if err != nil {
+		return
+	}
+	if statusCode != http.StatusOK {
+		return nil, parseAPIError(statusCode, body)
+	}
+
+	err = json.Unmarshal(body, &symbols)
+	if err != nil {
+		return
+	}
+
+	api.symbols.mu.Lock()
+	api.symbols.symbols = symbols
+	api.symbols.mu.Unlock()
+
+	return
+}
+
+// SymbolDetails returns tick size, quote increment, and other trading
+// metadata for symbol, fetching and caching it on first call.
+func (api *API) SymbolDetails(ctx context.Context, symbol string) (detail SymbolDetail, err error) {
+	symbol = strings.ToLower(symbol)
+
+	api.symbols.mu.RLock()
+	cached, ok := api.symbols.details[symbol]
+	api.symbols.mu.RUnlock()
+	if ok {
+		return cached, nil
+	}
+
+	body, statusCode, err := api.get(ctx, "/v1/symbols/details/"+symbol)
+	if err != nil {
+		return
+	}
+	if statusCode != http.StatusOK {
+		return detail, parseAPIError(statusCode, body)
+	}
+
+	err = json.Unmarshal(body, &detail)
+	if err != nil {
+		return
+	}
+
+	api.symbols.mu.Lock()
+	if api.symbols.details == nil {
+		api.symbols.details = make(map[string]SymbolDetail)
+	}
+	api.symbols.details[symbol] = detail
+	api.symbols.mu.Unlock()
+
+	return
+}
+
+// RoundPrice snaps price down to symbol's quote increment so NewOrder
+// doesn't reject it with InvalidPrice.
+func (api *API) RoundPrice(ctx context.Context, symbol string, price float64) (float64, error) {
+	detail, err := api.SymbolDetails(ctx, symbol)
+	if err != nil {
+		return 0, err
+	}
+	return roundToIncrement(price, detail.QuoteIncrement), nil
+}
+
+// RoundAmount snaps amount down to symbol's tick size so NewOrder doesn't
+// reject it with InvalidQuantity.
+func (api *API) RoundAmount(ctx context.Context, symbol string, amount float64) (float64, error) {
+	detail, err := api.SymbolDetails(ctx, symbol)
+	if err != nil {
+		return 0, err
+	}
+	return roundToIncrement(amount, detail.TickSize), nil
+}
+
+// roundToIncrement rounds value down to the nearest multiple of increment,
+// so the result never trips an exchange-side "too precise" rejection. A
+// small epsilon guards against float64 division error pushing an
+// already-valid, exactly-incremented value down a full increment (e.g.
+// 19.99/0.01 landing on 1998.9999999999998 instead of 1999).
+func roundToIncrement(value, increment float64) float64 {
+	if increment <= 0 {
+		return value
+	}
+	const epsilon = 1e-9
+	return math.Floor(value/increment+epsilon) * increment
+}